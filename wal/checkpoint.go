@@ -0,0 +1,174 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package wal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/batchrepr"
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/record"
+)
+
+// checkpointPrefix names the synthetic segment files produced by Checkpoint.
+// Like the normal "NNNNNN.log" segments, the numeric suffix is a NumWAL, but
+// unlike a normal segment a checkpoint file stands in for an entire
+// contiguous run of logical WALs: the lowest-numbered WAL among those it
+// replaces.
+const checkpointPrefix = "checkpoint."
+
+// makeCheckpointFilename returns the name of the checkpoint file
+// representing logNum, the lowest-numbered logical WAL folded into it.
+func makeCheckpointFilename(logNum NumWAL) string {
+	return fmt.Sprintf("%s%06d", checkpointPrefix, uint64(base.DiskFileNum(logNum)))
+}
+
+// parseCheckpointFilename parses a checkpoint filename produced by
+// makeCheckpointFilename, returning the NumWAL it stands in for.
+func parseCheckpointFilename(name string) (NumWAL, bool) {
+	suffix, ok := strings.CutPrefix(name, checkpointPrefix)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(suffix, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return NumWAL(base.DiskFileNum(n)), true
+}
+
+// Checkpoint compacts every logical WAL in dirs numbered less than upTo into
+// a single new checkpoint file, discarding records for which keep returns
+// false. It's used to trim WALs that are still needed for recovery (because
+// a memtable flush hasn't yet made them obsolete) down to just the records
+// that remain relevant, rather than waiting for the whole WAL to become
+// unnecessary.
+//
+// keep is invoked with the header of every batch still present in the
+// merged WALs, including LogData-only batches (those with a header Count of
+// zero); a typical predicate retains batches whose sequence number is above
+// the highest sequence number already durably flushed to the LSM.
+//
+// useRecordCodecs must match how the WALs being merged were written: set it
+// only if every one of them was written through a RecordWriter. Like
+// OpenForRead's identically-named option, getting this wrong misinterprets
+// each batch's own sequence number as a codec byte. The checkpoint file
+// Checkpoint produces is always written through compressRecord, regardless
+// of this setting, since it's a new file with no compatibility constraint of
+// its own.
+//
+// toMerge's WALs — every logical WAL numbered less than upTo — are folded
+// into a single checkpoint file named after the lowest of them; every
+// NumWAL in that range other than the lowest ceases to have its own segment
+// on disk once Checkpoint succeeds. Callers that track individual WALs by
+// number (e.g. waiting for one to become obsolete) must treat the whole
+// range as having been replaced at once, not number by number.
+//
+// On success the original segments that were folded into the checkpoint are
+// removed; if Checkpoint fails before the checkpoint file is synced, the
+// original segments are left untouched and no partially-written checkpoint
+// file is left behind for a subsequent listLogs to discover. If removing an
+// original segment fails, Checkpoint returns that error with the synced
+// checkpoint file in place and the segments not yet removed still present.
+func Checkpoint(dirs []Dir, upTo NumWAL, useRecordCodecs bool, keep func(batchrepr.Header) bool) error {
+	if len(dirs) == 0 {
+		return errors.New("wal: Checkpoint requires at least one directory")
+	}
+	wals, err := listLogs(dirs...)
+	if err != nil {
+		return err
+	}
+
+	var toMerge []logicalWAL
+	for _, lw := range wals {
+		if lw.NumWAL < upTo {
+			toMerge = append(toMerge, lw)
+		}
+	}
+	if len(toMerge) == 0 {
+		return nil
+	}
+
+	dir := dirs[0]
+	ckptName := makeCheckpointFilename(toMerge[0].NumWAL)
+	ckptPath := dir.FS.PathJoin(dir.Dirname, ckptName)
+	f, err := dir.FS.Create(ckptPath)
+	if err != nil {
+		return errors.Wrapf(err, "creating checkpoint file %q", ckptPath)
+	}
+	// Close f unconditionally so we never leak the fd, and additionally
+	// clean up a partial checkpoint file on any failure so a subsequent
+	// listLogs never observes a half-written one.
+	success := false
+	defer func() {
+		_ = f.Close()
+		if !success {
+			_ = dir.FS.Remove(ckptPath)
+		}
+	}()
+
+	w := record.NewWriter(f)
+	var scratch []byte
+	for _, lw := range toMerge {
+		r := newVirtualWALReaderOpts(lw.NumWAL, lw.segments,
+			false /* repairTail */, useRecordCodecs, true /* returnLogData */)
+		for {
+			rec, _, err := r.NextRecord()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				_ = r.Close()
+				return errors.Wrapf(err, "reading WAL %s during checkpoint", lw.NumWAL)
+			}
+			var buf bytes.Buffer
+			if _, err := buf.ReadFrom(rec); err != nil {
+				_ = r.Close()
+				return err
+			}
+			h, ok := batchrepr.ReadHeader(buf.Bytes())
+			if !ok || !keep(h) {
+				continue
+			}
+			rw, err := w.Next()
+			if err != nil {
+				_ = r.Close()
+				return errors.Wrapf(err, "writing checkpoint record")
+			}
+			var payload []byte
+			payload, scratch = compressRecord(buf.Bytes(), defaultCompressionMinSize, nil, scratch)
+			if _, err := rw.Write(payload); err != nil {
+				_ = r.Close()
+				return errors.Wrapf(err, "writing checkpoint record")
+			}
+		}
+		if err := r.Close(); err != nil {
+			return errors.Wrapf(err, "closing WAL %s during checkpoint", lw.NumWAL)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return errors.Wrapf(err, "closing checkpoint file %q", ckptPath)
+	}
+	if err := f.Sync(); err != nil {
+		return errors.Wrapf(err, "syncing checkpoint file %q", ckptPath)
+	}
+	success = true
+
+	// Now that the checkpoint is durable, remove the segments it replaced.
+	for _, lw := range toMerge {
+		for _, seg := range lw.segments {
+			path := seg.dir.FS.PathJoin(seg.dir.Dirname, makeLogFilename(lw.NumWAL, seg.logNameIndex))
+			if err := seg.dir.FS.Remove(path); err != nil {
+				return errors.Wrapf(err, "removing checkpointed segment %q", path)
+			}
+		}
+	}
+	return nil
+}
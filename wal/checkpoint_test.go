@@ -0,0 +1,85 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package wal
+
+import (
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/cockroachdb/pebble/batchrepr"
+	"github.com/cockroachdb/pebble/record"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+// makeLogDataBatch constructs a LogData-only batch: like makeBatch, but with
+// a header count of zero, as real LogData-only batches encode.
+func makeLogDataBatch(seqNum uint64, data string) []byte {
+	buf := make([]byte, 12+len(data))
+	binary.LittleEndian.PutUint64(buf[0:8], seqNum)
+	binary.LittleEndian.PutUint32(buf[8:12], 0)
+	copy(buf[12:], data)
+	return buf
+}
+
+// TestCheckpointSurvivorship verifies that Checkpoint lets keep vote on
+// LogData-only batches rather than silently dropping them, and that doing so
+// doesn't cause the following real batch (which legitimately shares the
+// LogData batch's sequence number) to be mistaken for a duplicate and
+// dropped too.
+func TestCheckpointSurvivorship(t *testing.T) {
+	fs := vfs.NewMem()
+	dir := Dir{FS: fs, Dirname: "wal"}
+	require.NoError(t, fs.MkdirAll(dir.Dirname, 0755))
+
+	const logNum = NumWAL(1)
+	path := fs.PathJoin(dir.Dirname, makeLogFilename(logNum, 0))
+	f, err := fs.Create(path)
+	require.NoError(t, err)
+	w := record.NewWriter(f)
+	batch1 := makeBatch(1, "alpha")
+	logData := makeLogDataBatch(2, "tag")
+	batch2 := makeBatch(2, "beta")
+	for _, rec := range [][]byte{batch1, logData, batch2} {
+		recWriter, err := w.Next()
+		require.NoError(t, err)
+		_, err = recWriter.Write(rec)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	var sawLogData bool
+	keep := func(h batchrepr.Header) bool {
+		if h.Count == 0 {
+			sawLogData = true
+			return false
+		}
+		return true
+	}
+	require.NoError(t, Checkpoint([]Dir{dir}, logNum+1, false /* useRecordCodecs */, keep))
+	require.True(t, sawLogData, "keep was never invoked for the LogData-only batch")
+
+	ckptPath := fs.PathJoin(dir.Dirname, makeCheckpointFilename(logNum))
+	r, err := OpenForRead([]Dir{dir}, logNum, ReaderOptions{UseRecordCodecs: true})
+	require.NoError(t, err)
+	defer r.Close()
+
+	for _, want := range [][]byte{batch1, batch2} {
+		rec, _, err := r.NextRecord()
+		require.NoError(t, err)
+		got, err := io.ReadAll(rec)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+	_, _, err = r.NextRecord()
+	require.ErrorIs(t, err, io.EOF)
+
+	_, err = fs.Stat(path)
+	require.Error(t, err, "original segment should have been removed")
+	_, err = fs.Stat(ckptPath)
+	require.NoError(t, err, "checkpoint file should exist")
+}
@@ -0,0 +1,169 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package wal
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/record"
+	"github.com/golang/snappy"
+)
+
+// recordCodec identifies how an individual logical WAL record's payload is
+// encoded on disk. It is distinct from the record.Reader/record.Writer
+// envelope and the CRC-checked fragmentation performed by internal/record:
+// the codec byte is the first byte of the payload carried within that
+// envelope, and everything after it is the encoded (e.g. compressed) batch
+// representation consumed by batchrepr.
+type recordCodec uint8
+
+const (
+	// codecRaw indicates the batch payload follows uncompressed, exactly as
+	// produced by batchrepr.
+	codecRaw recordCodec = 0
+	// codecSnappy indicates the batch payload is Snappy-compressed.
+	codecSnappy recordCodec = 1
+	// codecZstd is reserved for a future zstd codec.
+	codecZstd recordCodec = 2
+
+	// codecHeaderLen is the width, in bytes, of the codec byte prepended to
+	// every record payload.
+	codecHeaderLen = 1
+)
+
+// builtinCodecs holds the Pebble-internal codecs, keyed by their codec byte.
+// They're always available, unlike external codecs which must be registered
+// with RegisterCodec.
+var builtinCodecs = map[uint8]RecordCodec{
+	uint8(codecRaw):    rawCodec{},
+	uint8(codecSnappy): snappyCodec{},
+}
+
+// rawCodec is the identity codec: the payload is stored as-is.
+type rawCodec struct{}
+
+func (rawCodec) ID() uint8                              { return uint8(codecRaw) }
+func (rawCodec) Encode(dst, src []byte) []byte          { return append(dst[:0], src...) }
+func (rawCodec) Decode(dst, src []byte) ([]byte, error) { return append(dst[:0], src...), nil }
+func (rawCodec) Validate(encodedPayload []byte) error   { return nil }
+
+// snappyCodec compresses the payload with Snappy.
+type snappyCodec struct{}
+
+func (snappyCodec) ID() uint8 { return uint8(codecSnappy) }
+
+func (snappyCodec) Encode(dst, src []byte) []byte {
+	return snappy.Encode(dst[:cap(dst)], src)
+}
+
+func (snappyCodec) Decode(dst, src []byte) ([]byte, error) {
+	n, err := snappy.DecodedLen(src)
+	if err != nil {
+		return nil, errors.Wrapf(err, "wal: invalid snappy record")
+	}
+	if cap(dst) < n {
+		dst = make([]byte, n)
+	}
+	decoded, err := snappy.Decode(dst[:n], src)
+	if err != nil {
+		return nil, errors.Wrapf(err, "wal: decompressing snappy record")
+	}
+	return decoded, nil
+}
+
+func (snappyCodec) Validate(encodedPayload []byte) error { return nil }
+
+// defaultCompressionMinSize is the smallest batch payload, in bytes, that
+// compressRecord will attempt to compress absent an explicit
+// RecordWriterOptions.CompressionMinSize. Smaller payloads are written raw
+// because Snappy's per-block overhead makes compression counterproductive.
+const defaultCompressionMinSize = 256
+
+// compressRecord prepends a codec byte to payload, appending the result to
+// dst and returning the extended slice. If payload is shorter than
+// minSize, or compressing it doesn't shrink it, the payload is written raw
+// (codecRaw). scratch is reused as scratch space for the compression
+// attempt and may be returned enlarged for the next call.
+func compressRecord(payload []byte, minSize int, dst []byte, scratch []byte) (_ []byte, scratchOut []byte) {
+	if len(payload) >= minSize {
+		encoded := snappyCodec{}.Encode(scratch, payload)
+		if len(encoded) < len(payload) {
+			dst = append(dst, byte(codecSnappy))
+			return append(dst, encoded...), encoded
+		}
+		scratch = encoded
+	}
+	dst = append(dst, byte(codecRaw))
+	return append(dst, payload...), scratch
+}
+
+// decompressRecord strips the leading codec byte from src and decodes the
+// remainder using whichever codec (built-in or externally registered via
+// RegisterCodec) claims that codec byte, returning the decoded payload.
+// *scratch is reused and grown as necessary across calls to avoid repeated
+// allocation. decompressRecord returns an error marked with ErrUnknownCodec
+// if no codec is registered for the record's codec byte.
+func decompressRecord(src []byte, scratch *[]byte) ([]byte, error) {
+	if len(src) < codecHeaderLen {
+		return nil, errors.Errorf("wal: record too short to contain a codec byte")
+	}
+	id := src[0]
+	payload := src[codecHeaderLen:]
+	codec, ok := lookupCodec(id)
+	if !ok {
+		return nil, errors.Mark(errors.Errorf("wal: record codec %d", id), ErrUnknownCodec)
+	}
+	decoded, err := codec.Decode(*scratch, payload)
+	if err != nil {
+		return nil, errors.Wrapf(err, "wal: decoding codec %d record", id)
+	}
+	*scratch = decoded
+	return decoded, nil
+}
+
+// RecordWriterOptions configures the RecordWriter returned by
+// NewRecordWriter.
+type RecordWriterOptions struct {
+	// CompressionMinSize is the smallest batch payload, in bytes, that the
+	// RecordWriter will attempt to compress; smaller payloads are written
+	// raw, since Snappy's per-block overhead makes compressing them
+	// counterproductive. Zero means defaultCompressionMinSize, which is
+	// right for most callers — batch size distributions vary enough across
+	// workloads that this is exposed rather than hardcoded.
+	CompressionMinSize int
+}
+
+// A RecordWriter wraps a record.Writer, prepending a codec byte to each
+// record it writes via compressRecord's compress-if-it-pays-off heuristic.
+// It's the writer-side counterpart that a reader constructed with
+// ReaderOptions.UseRecordCodecs requires: only logical WALs written through
+// a RecordWriter carry a leading codec byte on every record, so only those
+// WALs may be opened with UseRecordCodecs set.
+type RecordWriter struct {
+	w              *record.Writer
+	compressMinLen int
+	scratch        []byte
+}
+
+// NewRecordWriter returns a RecordWriter that writes records to w.
+func NewRecordWriter(w *record.Writer, opts RecordWriterOptions) *RecordWriter {
+	minSize := opts.CompressionMinSize
+	if minSize == 0 {
+		minSize = defaultCompressionMinSize
+	}
+	return &RecordWriter{w: w, compressMinLen: minSize}
+}
+
+// WriteRecord writes payload (a batchrepr-encoded batch) as a new record,
+// transparently compressing it when doing so pays off.
+func (rw *RecordWriter) WriteRecord(payload []byte) error {
+	recWriter, err := rw.w.Next()
+	if err != nil {
+		return err
+	}
+	var encoded []byte
+	encoded, rw.scratch = compressRecord(payload, rw.compressMinLen, nil, rw.scratch)
+	_, err = recWriter.Write(encoded)
+	return err
+}
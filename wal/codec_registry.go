@@ -0,0 +1,163 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package wal
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/record"
+)
+
+// minExternalCodec is the lowest codec ID available for codecs registered
+// by users of the wal package via RegisterCodec. IDs below minExternalCodec
+// are reserved for Pebble-internal codecs (codecRaw, codecSnappy,
+// codecZstd).
+const minExternalCodec = 128
+
+// RecordCodec is the interface implemented by both Pebble-internal and
+// externally registered record codecs. A codec frames the on-disk
+// representation of a single WAL record's payload, identified by the
+// leading codec byte that virtualWALReader.NextRecord peels off before
+// handing the remainder to batchrepr.
+//
+// External codecs let downstream users of Pebble (e.g. CockroachDB) layer
+// their own per-record framing — encryption-at-rest, application-level
+// checksums, a different compressor — onto the WAL without forking Pebble.
+// Register one with RegisterCodec.
+type RecordCodec interface {
+	// ID returns the codec byte this codec encodes and decodes. External
+	// codecs must return a value >= minExternalCodec.
+	ID() uint8
+	// Encode encodes src, returning the result. dst is used as scratch
+	// space when it has sufficient capacity, to avoid an allocation; unlike
+	// append, its existing contents are not preserved as a prefix.
+	Encode(dst, src []byte) []byte
+	// Decode decodes src, returning the result. dst is used as scratch
+	// space when it has sufficient capacity, to avoid an allocation; unlike
+	// append, its existing contents are not preserved as a prefix.
+	Decode(dst, src []byte) ([]byte, error)
+	// Validate is invoked by ValidateSegments, once for each segment whose
+	// first record's codec byte identifies this codec, with that record's
+	// still-encoded payload (i.e. the bytes Encode produced, before Decode
+	// converts them back); segments whose first record uses another codec
+	// are not passed to it. Open does not call ValidateSegments itself.
+	// It gives the codec an opportunity to refuse to proceed —
+	// for example because a decryption key it needs isn't available in
+	// this process — by returning a non-nil error. A nil return permits
+	// recovery of the segment to continue.
+	Validate(encodedPayload []byte) error
+}
+
+// ErrUnknownCodec is returned (wrapped, via errors.Mark) when a record's
+// codec byte doesn't correspond to any codec registered in this process,
+// whether built in or externally registered with RegisterCodec. Callers can
+// check for it with errors.Is and, e.g., attempt to load a plugin that
+// registers the missing codec before retrying.
+var ErrUnknownCodec = errors.New("wal: unknown record codec")
+
+// codecRegistry holds externally registered codecs, keyed by their codec
+// byte. Pebble-internal codecs live in builtinCodecs instead and are always
+// available.
+var codecRegistry sync.Map // uint8 -> RecordCodec
+
+// RegisterCodec registers an external RecordCodec, making it available to
+// virtualWALReader.NextRecord for any record whose codec byte matches
+// codec.ID(). It's typically called from an init function by a downstream
+// user of the wal package. codec.ID() must be >= minExternalCodec;
+// RegisterCodec panics otherwise, since that would either collide with or
+// attempt to override a Pebble-internal codec.
+//
+// RegisterCodec is not safe to call concurrently with WAL recovery.
+func RegisterCodec(codec RecordCodec) {
+	if codec.ID() < minExternalCodec {
+		panic(errors.Errorf("wal: external codec ID %d must be >= %d", codec.ID(), minExternalCodec))
+	}
+	codecRegistry.Store(codec.ID(), codec)
+}
+
+// lookupCodec returns the codec registered for id — checking Pebble-internal
+// codecs first, then externally registered ones — or false if none is
+// registered.
+func lookupCodec(id uint8) (RecordCodec, bool) {
+	if c, ok := builtinCodecs[id]; ok {
+		return c, true
+	}
+	v, ok := codecRegistry.Load(id)
+	if !ok {
+		return nil, false
+	}
+	return v.(RecordCodec), true
+}
+
+// ValidateSegments gives the codec used by each segment found in dirs a
+// chance to inspect it before recovery proceeds, by invoking
+// RecordCodec.Validate with the encoded payload of that segment's first
+// record. It's intended to be called once, early during Open, so that a
+// codec needing state that isn't available in this process (e.g. an
+// encryption key) fails fast with a clear error rather than once recovery
+// reaches an affected record deep into a segment.
+//
+// useRecordCodecs must match how the WALs in dirs were written, exactly as
+// for OpenForRead's identically-named option. If false, segments carry no
+// codec byte at all, and ValidateSegments is a no-op: there is nothing for a
+// codec to validate against.
+func ValidateSegments(useRecordCodecs bool, dirs ...Dir) error {
+	if !useRecordCodecs {
+		return nil
+	}
+	wals, err := listLogs(dirs...)
+	if err != nil {
+		return err
+	}
+	for _, lw := range wals {
+		for _, seg := range lw.segments {
+			if err := validateSegment(lw.NumWAL, seg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateSegment reads just far enough into seg to decode its first
+// record's envelope, then hands that record's still-encoded payload to
+// whichever codec its leading codec byte identifies. A segment with no
+// records, or whose first record is unreadable, has nothing reliable to
+// validate against and is silently skipped — Repair is responsible for
+// flagging a segment in that state, not ValidateSegments.
+func validateSegment(logNum NumWAL, seg segment) error {
+	fs := seg.dir.FS
+	path := fs.PathJoin(seg.dir.Dirname, makeLogFilename(logNum, seg.logNameIndex))
+	f, err := fs.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "opening WAL segment %q", path)
+	}
+	defer f.Close()
+
+	rr := record.NewReader(f, base.DiskFileNum(logNum))
+	rec, err := rr.Next()
+	if err != nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(rec); err != nil {
+		return errors.Wrapf(err, "reading WAL segment %q", path)
+	}
+	if buf.Len() < codecHeaderLen {
+		return nil
+	}
+	id := buf.Bytes()[0]
+	codec, ok := lookupCodec(id)
+	if !ok {
+		return nil
+	}
+	if err := codec.Validate(buf.Bytes()[codecHeaderLen:]); err != nil {
+		return errors.Wrapf(err, "validating WAL segment %q against codec %d", path, id)
+	}
+	return nil
+}
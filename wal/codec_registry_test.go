@@ -0,0 +1,72 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package wal
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/pebble/record"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeExternalCodec is a RecordCodec stand-in for a downstream-registered
+// codec (e.g. one that layers encryption onto records), used to observe what
+// ValidateSegments hands to Validate.
+type fakeExternalCodec struct {
+	id       uint8
+	validate func([]byte) error
+}
+
+func (c fakeExternalCodec) ID() uint8                     { return c.id }
+func (c fakeExternalCodec) Encode(dst, src []byte) []byte { return append(dst[:0], src...) }
+func (c fakeExternalCodec) Decode(dst, src []byte) ([]byte, error) {
+	return append(dst[:0], src...), nil
+}
+func (c fakeExternalCodec) Validate(encodedPayload []byte) error { return c.validate(encodedPayload) }
+
+// TestValidateSegmentsUsesFirstRecord verifies that ValidateSegments hands
+// Validate the actual encoded payload carried by a segment's first record —
+// not an arbitrary prefix of the raw file, which is internal/record framing
+// rather than anything a codec produced — and that it routes to the codec
+// identified by that record's leading codec byte.
+func TestValidateSegmentsUsesFirstRecord(t *testing.T) {
+	fs := vfs.NewMem()
+	dir := Dir{FS: fs, Dirname: "wal"}
+	require.NoError(t, fs.MkdirAll(dir.Dirname, 0755))
+	const logNum = NumWAL(9)
+	const externalID = 200
+
+	encodedPayload := []byte("ciphertext-stand-in")
+	path := fs.PathJoin(dir.Dirname, makeLogFilename(logNum, 0))
+	f, err := fs.Create(path)
+	require.NoError(t, err)
+	w := record.NewWriter(f)
+	recWriter, err := w.Next()
+	require.NoError(t, err)
+	_, err = recWriter.Write(append([]byte{externalID}, encodedPayload...))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	var got []byte
+	RegisterCodec(fakeExternalCodec{
+		id: externalID,
+		validate: func(encodedPayload []byte) error {
+			got = append([]byte(nil), encodedPayload...)
+			return nil
+		},
+	})
+
+	require.NoError(t, ValidateSegments(true /* useRecordCodecs */, dir))
+	require.Equal(t, encodedPayload, got)
+
+	// With useRecordCodecs false, ValidateSegments has no codec byte to
+	// work from at all and must be a no-op, regardless of what's
+	// registered.
+	got = []byte("sentinel")
+	require.NoError(t, ValidateSegments(false /* useRecordCodecs */, dir))
+	require.Equal(t, []byte("sentinel"), got)
+}
@@ -0,0 +1,110 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package wal
+
+import (
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/pebble/record"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+// makeBatch constructs a minimal valid batchrepr-encoded batch: an 8-byte
+// little-endian sequence number, a 4-byte little-endian count, followed by
+// arbitrary data.
+func makeBatch(seqNum uint64, data string) []byte {
+	buf := make([]byte, 12+len(data))
+	binary.LittleEndian.PutUint64(buf[0:8], seqNum)
+	binary.LittleEndian.PutUint32(buf[8:12], 1)
+	copy(buf[12:], data)
+	return buf
+}
+
+func TestRecordWriterRoundTrip(t *testing.T) {
+	fs := vfs.NewMem()
+	dir := Dir{FS: fs, Dirname: "wal"}
+	require.NoError(t, fs.MkdirAll(dir.Dirname, 0755))
+
+	const logNum = NumWAL(1)
+	path := fs.PathJoin(dir.Dirname, makeLogFilename(logNum, 0))
+	f, err := fs.Create(path)
+	require.NoError(t, err)
+
+	w := record.NewWriter(f)
+	rw := NewRecordWriter(w, RecordWriterOptions{})
+	small := makeBatch(1, "small")
+	large := makeBatch(2, strings.Repeat("x", 512))
+	require.NoError(t, rw.WriteRecord(small))
+	require.NoError(t, rw.WriteRecord(large))
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	r, err := OpenForRead([]Dir{dir}, logNum, ReaderOptions{UseRecordCodecs: true})
+	require.NoError(t, err)
+	defer r.Close()
+
+	for _, want := range [][]byte{small, large} {
+		rec, _, err := r.NextRecord()
+		require.NoError(t, err)
+		got, err := io.ReadAll(rec)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+	_, _, err = r.NextRecord()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+// TestLegacyWALReadsRaw verifies that a WAL written by a plain record.Writer
+// (i.e. every WAL that predates RecordWriter) is read back byte-for-byte
+// when ReaderOptions.UseRecordCodecs is left unset, rather than having its
+// leading bytes misinterpreted as a codec byte.
+func TestLegacyWALReadsRaw(t *testing.T) {
+	fs := vfs.NewMem()
+	dir := Dir{FS: fs, Dirname: "wal"}
+	require.NoError(t, fs.MkdirAll(dir.Dirname, 0755))
+
+	const logNum = NumWAL(2)
+	path := fs.PathJoin(dir.Dirname, makeLogFilename(logNum, 0))
+	f, err := fs.Create(path)
+	require.NoError(t, err)
+
+	w := record.NewWriter(f)
+	batch := makeBatch(1, "legacy")
+	recWriter, err := w.Next()
+	require.NoError(t, err)
+	_, err = recWriter.Write(batch)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	r, err := OpenForRead([]Dir{dir}, logNum, ReaderOptions{})
+	require.NoError(t, err)
+	defer r.Close()
+
+	rec, _, err := r.NextRecord()
+	require.NoError(t, err)
+	got, err := io.ReadAll(rec)
+	require.NoError(t, err)
+	require.Equal(t, batch, got)
+}
+
+// TestCompressRecordMinSize verifies that compressRecord's threshold for
+// attempting compression is the minSize it's called with, not a fixed
+// constant, so that RecordWriterOptions.CompressionMinSize actually has an
+// effect.
+func TestCompressRecordMinSize(t *testing.T) {
+	payload := makeBatch(1, strings.Repeat("x", 64))
+	require.Less(t, len(payload), defaultCompressionMinSize)
+
+	encoded, _ := compressRecord(payload, defaultCompressionMinSize, nil, nil)
+	require.Equal(t, byte(codecRaw), encoded[0], "payload is below the default threshold")
+
+	encoded, _ = compressRecord(payload, len(payload), nil, nil)
+	require.Equal(t, byte(codecSnappy), encoded[0], "payload is compressible and at/above the given minSize")
+}
@@ -0,0 +1,256 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package wal
+
+import (
+	"io"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/record"
+)
+
+// liveReaderMinBackoff and liveReaderMaxBackoff bound the delay between
+// successive polls of a WAL segment that currently has no new data for us.
+const (
+	liveReaderMinBackoff = time.Millisecond
+	liveReaderMaxBackoff = 100 * time.Millisecond
+)
+
+// NewLiveReader constructs a LiveReader that tails the logical WAL
+// identified by logNum, following it through segment rotations and
+// failovers as new data is fsynced. Unlike a Reader returned by OpenForRead,
+// a LiveReader never returns io.EOF once it has located the WAL: instead it
+// blocks (with backoff) until either new data appears or the reader is
+// closed.
+//
+// NewLiveReader is intended for use by consumers that want to observe
+// batches as Pebble commits them, such as external replication/CDC
+// consumers, rather than by WAL recovery during Open.
+func NewLiveReader(logNum NumWAL, dirs ...Dir) (*LiveReader, error) {
+	wals, err := listLogs(dirs...)
+	if err != nil {
+		return nil, err
+	}
+	lw, ok := wals.get(logNum)
+	if !ok {
+		// The WAL may not have any segments yet if the caller races the
+		// writer's creation of the first segment. Begin with an empty
+		// segment list; pollForNewSegment will pick it up once it's created.
+		lw = logicalWAL{NumWAL: logNum}
+	}
+	return &LiveReader{
+		dirs:  dirs,
+		inner: newVirtualWALReader(logNum, lw.segments),
+	}, nil
+}
+
+// A LiveReader follows a still-open logical WAL, yielding records as they're
+// fsynced rather than returning io.EOF once it catches up to the current
+// tail. It tolerates the specific form of incompleteness produced by a
+// record.Writer that's mid-write: a page that's only partially filled in,
+// the remainder of which reads back as zeroes.
+type LiveReader struct {
+	dirs    []Dir
+	inner   *virtualWALReader
+	backoff time.Duration
+	closed  bool
+}
+
+// NextRecord returns a reader for the next record, blocking until one
+// becomes available. It only returns an error if the reader encounters
+// corruption that isn't explainable by a torn in-progress write, or if the
+// LiveReader has been closed.
+func (r *LiveReader) NextRecord() (io.Reader, Offset, error) {
+	for {
+		if r.closed {
+			return nil, Offset{}, errors.New("wal: LiveReader is closed")
+		}
+		rec, off, err := r.inner.NextRecord()
+		switch {
+		case err == nil:
+			r.backoff = 0
+			return rec, off, nil
+		case !errors.Is(err, io.EOF) && !record.IsInvalidRecord(err):
+			// A genuine error unrelated to reaching the current tail.
+			return nil, off, err
+		}
+
+		// We've reached what looks like the end of the current segment,
+		// either via a clean io.EOF or a malformed trailing record. Before
+		// concluding that we must wait for more data, check whether a new
+		// segment has appeared (e.g. because of a WAL failover or a
+		// rotation at flush). If so, the current segment is sealed and we
+		// should continue reading from the new one immediately rather than
+		// waiting on a page that will never receive more bytes.
+		grew, lerr := r.pollForNewSegment()
+		if lerr != nil {
+			return nil, off, lerr
+		}
+		if grew {
+			r.backoff = 0
+			continue
+		}
+
+		// No new segment has appeared. The current segment itself may still
+		// have grown in place: currReader's view of the file, including any
+		// padding newPaddedSegmentReader synthesized past its previously
+		// final block, was fixed when we opened it and can't observe bytes
+		// fsynced since. Reopen the same segment fresh so the next read sees
+		// its current size; lastSeqNum dedup in virtualWALReader.NextRecord
+		// skips back over whatever we've already returned.
+		// currentSegmentGrew reopens the segment itself when currFile was
+		// already nil (that's the only way it can learn the file's current
+		// size), so note that before calling it: if it was still open,
+		// currentSegmentGrew only Stat'd it, and we still need to reopen it
+		// fresh here; if it was nil, there's nothing left to do below.
+		wasOpen := r.inner.currFile != nil
+		segGrew, serr := r.currentSegmentGrew()
+		if serr != nil {
+			return nil, off, serr
+		}
+		if segGrew {
+			if wasOpen {
+				r.inner.currIndex--
+				if err := r.inner.nextFile(); err != nil {
+					return nil, off, err
+				}
+			}
+			r.backoff = 0
+			continue
+		}
+
+		// No new bytes anywhere. If the trailing bytes of the current
+		// segment are still zero-filled, a writer may be mid-page; sleep and
+		// retry. If they're not, and NextRecord returned a real error
+		// (rather than io.EOF), surface it: it isn't explainable by a torn
+		// write.
+		tornTail, terr := r.currentTailIsZeroFilled()
+		if terr != nil {
+			return nil, off, terr
+		}
+		if !tornTail && !errors.Is(err, io.EOF) {
+			return nil, off, err
+		}
+		r.sleep()
+	}
+}
+
+// pollForNewSegment re-lists the configured directories and appends any
+// newly discovered segments belonging to this logical WAL to the underlying
+// virtualWALReader, returning true if any were found.
+func (r *LiveReader) pollForNewSegment() (bool, error) {
+	wals, err := listLogs(r.dirs...)
+	if err != nil {
+		return false, err
+	}
+	lw, ok := wals.get(r.inner.logNum)
+	if !ok || len(lw.segments) <= len(r.inner.segments) {
+		return false, nil
+	}
+	r.inner.segments = lw.segments
+	if r.inner.currFile == nil {
+		// The previously known-last segment hit a clean io.EOF: nextFile
+		// already advanced currIndex one past the end of the (shorter) old
+		// segment list and returned io.EOF without opening anything, since
+		// that index was out of bounds at the time. Now that segments has
+		// grown, that same index refers to the real, newly discovered
+		// segment. Rewind by one and open it directly — the torn-tail path
+		// below doesn't need this, since there currFile is still open and
+		// nextFile gets re-invoked naturally once currIndex compares less
+		// than the grown segment list's length.
+		r.inner.currIndex--
+		if err := r.inner.nextFile(); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// currentSegmentGrew reports whether the segment we're positioned in has, on
+// disk, grown past the size virtualWALReader.curSegmentSize recorded when it
+// was opened — meaning a writer has fsynced more data into it since, which
+// our current currReader can't see no matter how many times it's polled.
+func (r *LiveReader) currentSegmentGrew() (bool, error) {
+	if r.inner.currFile == nil {
+		// nextFile already closed and nil'd currFile after a clean io.EOF on
+		// what was, at the time, the last known segment, advancing currIndex
+		// out of bounds; pollForNewSegment found no new segment index, so
+		// the only way for more data to have appeared is for that same
+		// segment to have grown in place. There's no open handle left to
+		// Stat, so reopen the segment directly — mirroring the
+		// currIndex--/nextFile reopen pollForNewSegment uses for a newly
+		// discovered segment — and compare its size against what
+		// curSegmentSize recorded before nextFile overwrites it.
+		if len(r.inner.segments) == 0 {
+			return false, nil
+		}
+		prevSize := r.inner.curSegmentSize
+		r.inner.currIndex = len(r.inner.segments) - 2
+		if err := r.inner.nextFile(); err != nil {
+			return false, err
+		}
+		return r.inner.curSegmentSize > prevSize, nil
+	}
+	info, err := r.inner.currFile.Stat()
+	if err != nil {
+		return false, errors.Wrapf(err, "stat'ing WAL segment")
+	}
+	return info.Size() > r.inner.curSegmentSize, nil
+}
+
+// currentTailIsZeroFilled reports whether the remainder of the current
+// page-aligned block within the segment we're positioned in is entirely
+// zero. A zero-filled remainder is consistent with record.Writer having
+// begun, but not yet completed, writing the next record's fragments into a
+// preallocated or page-padded region, as opposed to genuine corruption.
+func (r *LiveReader) currentTailIsZeroFilled() (bool, error) {
+	f := r.inner.currFile
+	if f == nil {
+		return false, nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false, errors.Wrapf(err, "stat'ing WAL segment")
+	}
+	pageStart := (r.inner.off.Physical / blockSize) * blockSize
+	pageEnd := pageStart + blockSize
+	if pageEnd > info.Size() {
+		pageEnd = info.Size()
+	}
+	if pageEnd <= r.inner.off.Physical {
+		return false, nil
+	}
+	buf := make([]byte, pageEnd-r.inner.off.Physical)
+	if _, err := f.ReadAt(buf, r.inner.off.Physical); err != nil && !errors.Is(err, io.EOF) {
+		return false, errors.Wrapf(err, "reading WAL segment tail")
+	}
+	for _, b := range buf {
+		if b != 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// sleep backs off before the next poll, growing the delay up to
+// liveReaderMaxBackoff.
+func (r *LiveReader) sleep() {
+	if r.backoff == 0 {
+		r.backoff = liveReaderMinBackoff
+	} else {
+		r.backoff *= 2
+		if r.backoff > liveReaderMaxBackoff {
+			r.backoff = liveReaderMaxBackoff
+		}
+	}
+	time.Sleep(r.backoff)
+}
+
+// Close closes the reader, releasing any open file.
+func (r *LiveReader) Close() error {
+	r.closed = true
+	return r.inner.Close()
+}
@@ -0,0 +1,115 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package wal
+
+import (
+	"io"
+	"testing"
+
+	"github.com/cockroachdb/pebble/record"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLiveReaderRotatesAcrossSealedSegment verifies that LiveReader picks up
+// a newly discovered segment after cleanly exhausting the previous one,
+// rather than skipping its first record. A sealed segment (one that was
+// fully written and closed, as opposed to one left with a torn tail) hits a
+// clean io.EOF, which exercises a different code path than the torn-tail
+// rotation case.
+func TestLiveReaderRotatesAcrossSealedSegment(t *testing.T) {
+	fs := vfs.NewMem()
+	dir := Dir{FS: fs, Dirname: "wal"}
+	require.NoError(t, fs.MkdirAll(dir.Dirname, 0755))
+	const logNum = NumWAL(7)
+
+	writeSegment := func(index logNameIndex, data string) {
+		path := fs.PathJoin(dir.Dirname, makeLogFilename(logNum, index))
+		f, err := fs.Create(path)
+		require.NoError(t, err)
+		w := record.NewWriter(f)
+		recWriter, err := w.Next()
+		require.NoError(t, err)
+		_, err = recWriter.Write(makeBatch(uint64(index)+1, data))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		require.NoError(t, f.Close())
+	}
+
+	// Only the first segment exists when the LiveReader is created.
+	writeSegment(0, "first")
+
+	r, err := NewLiveReader(logNum, dir)
+	require.NoError(t, err)
+	defer r.Close()
+
+	rec, _, err := r.NextRecord()
+	require.NoError(t, err)
+	got, err := io.ReadAll(rec)
+	require.NoError(t, err)
+	require.Equal(t, makeBatch(1, "first"), got)
+
+	// Seal the second segment before asking for the next record, so the
+	// LiveReader discovers it only after cleanly exhausting the first.
+	writeSegment(1, "second")
+
+	rec, _, err = r.NextRecord()
+	require.NoError(t, err)
+	got, err = io.ReadAll(rec)
+	require.NoError(t, err)
+	require.Equal(t, makeBatch(2, "second"), got)
+}
+
+// TestLiveReaderObservesGrowthInCurrentSegment verifies that LiveReader
+// surfaces a record appended to the segment it's already positioned in,
+// rather than only ever advancing when a whole new segment appears. It
+// exercises a still-open record.Writer that fsyncs a second record into the
+// same segment file after the LiveReader has already caught up to what
+// looked like the end of it.
+func TestLiveReaderObservesGrowthInCurrentSegment(t *testing.T) {
+	fs := vfs.NewMem()
+	dir := Dir{FS: fs, Dirname: "wal"}
+	require.NoError(t, fs.MkdirAll(dir.Dirname, 0755))
+	const logNum = NumWAL(8)
+
+	path := fs.PathJoin(dir.Dirname, makeLogFilename(logNum, 0))
+	f, err := fs.Create(path)
+	require.NoError(t, err)
+	w := record.NewWriter(f)
+	recWriter, err := w.Next()
+	require.NoError(t, err)
+	_, err = recWriter.Write(makeBatch(1, "first"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+
+	r, err := NewLiveReader(logNum, dir)
+	require.NoError(t, err)
+	defer r.Close()
+
+	rec, _, err := r.NextRecord()
+	require.NoError(t, err)
+	got, err := io.ReadAll(rec)
+	require.NoError(t, err)
+	require.Equal(t, makeBatch(1, "first"), got)
+
+	// Append a second record to the same, still-open segment without
+	// rotating. This requires NextRecord (which has already hit what looks
+	// like the end of the segment once, internally) to notice the segment
+	// grew and re-read it, rather than spinning forever on a stale reader.
+	recWriter, err = w.Next()
+	require.NoError(t, err)
+	_, err = recWriter.Write(makeBatch(2, "second"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+
+	rec, _, err = r.NextRecord()
+	require.NoError(t, err)
+	got, err = io.ReadAll(rec)
+	require.NoError(t, err)
+	require.Equal(t, makeBatch(2, "second"), got)
+
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+}
@@ -0,0 +1,44 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package wal
+
+// ReaderOptions configures the Reader returned by OpenForRead.
+type ReaderOptions struct {
+	// RepairTail opts into truncating the final segment back to the last
+	// valid record boundary once the reader has tolerated a torn write
+	// there, so that the same torn tail isn't re-discovered (and
+	// re-tolerated) the next time this logical WAL is opened. This mutates
+	// the segment file and so is only appropriate for the reader used
+	// during WAL recovery at Open, not for a read-only use like LiveReader
+	// or Checkpoint.
+	RepairTail bool
+	// UseRecordCodecs opts into treating the leading byte of every record's
+	// payload as a codec byte (see RecordWriter and codec.go), decoding it
+	// accordingly before the remainder is interpreted as a batch.
+	//
+	// This must only be set for a logical WAL known to have been written
+	// with a RecordWriter. Every other WAL — in particular every WAL
+	// written before this option existed — carries raw batchrepr bytes
+	// with no leading codec byte; decoding those unconditionally would
+	// misinterpret the low byte of the batch's own sequence number as a
+	// codec byte and corrupt recovery.
+	UseRecordCodecs bool
+}
+
+// OpenForRead opens a Reader over the logical WAL numbered logNum, merging
+// whatever segments for it are found across dirs. It's the exported
+// counterpart to the readers LiveReader and Checkpoint construct
+// internally; like LiveReader's, and unlike Checkpoint's, it skips
+// LogData-only batches.
+func OpenForRead(dirs []Dir, logNum NumWAL, opts ReaderOptions) (Reader, error) {
+	wals, err := listLogs(dirs...)
+	if err != nil {
+		return nil, err
+	}
+	lw, _ := wals.get(logNum)
+	return newVirtualWALReaderOpts(
+		logNum, lw.segments, opts.RepairTail, opts.UseRecordCodecs, false, /* returnLogData */
+	), nil
+}
@@ -69,18 +69,59 @@ func (wals logicalWALs) get(num NumWAL) (logicalWAL, bool) {
 }
 
 // listLogs finds all log files in the provided directories. It returns an
-// ordered list of WALs in increasing NumWAL order.
+// ordered list of WALs in increasing NumWAL order. It's an error for two
+// segments to share the same (NumWAL, logNameIndex): that's not a shape a
+// functioning WAL manager ever produces, and recovery has no principled way
+// to pick between them.
 func listLogs(dirs ...Dir) (logicalWALs, error) {
+	wals, dups, err := scanLogs(dirs...)
+	if err != nil {
+		return nil, err
+	}
+	if len(dups) > 0 {
+		d := dups[0]
+		return nil, errors.Errorf("wal: duplicate logIndex=%s for WAL %s in %s and %s",
+			d.logNameIndex, d.numWAL, d.dup.dir.Dirname, d.kept.dir.Dirname)
+	}
+	return wals, nil
+}
+
+// duplicateSegment records a collision scanLogs encountered: two segment
+// files claiming the same (numWAL, logNameIndex). kept is the one scanLogs
+// retained (the first encountered, in directory-listing order); dup is the
+// one it set aside.
+type duplicateSegment struct {
+	numWAL       NumWAL
+	logNameIndex logNameIndex
+	kept, dup    segment
+}
+
+// scanLogs is listLogs' shared implementation, additionally used by Repair,
+// which — unlike every other caller — needs to tolerate and describe a
+// duplicate logNameIndex rather than treat it as fatal. It never fails
+// merely because of such a duplicate: it keeps the first segment it finds
+// for a given (NumWAL, logNameIndex) and reports every later one it
+// encounters via the returned []duplicateSegment, leaving the caller to
+// decide what a duplicate means for it.
+func scanLogs(dirs ...Dir) (logicalWALs, []duplicateSegment, error) {
 	var wals []logicalWAL
+	var dups []duplicateSegment
 	for _, d := range dirs {
 		ls, err := d.FS.List(d.Dirname)
 		if err != nil {
-			return nil, errors.Wrapf(err, "reading %q", d.Dirname)
+			return nil, nil, errors.Wrapf(err, "reading %q", d.Dirname)
 		}
 		for _, name := range ls {
 			dfn, li, ok := parseLogFilename(name)
 			if !ok {
-				continue
+				// A checkpoint file produced by Checkpoint stands in for
+				// the logical WAL it replaced, appearing as that WAL's
+				// lone, lowest-numbered segment.
+				dfn, ok = parseCheckpointFilename(name)
+				if !ok {
+					continue
+				}
+				li = 0
 			}
 			// Have we seen this logical log number yet?
 			i, found := slices.BinarySearchFunc(wals, dfn, func(lw logicalWAL, n NumWAL) int {
@@ -96,20 +137,43 @@ func listLogs(dirs ...Dir) (logicalWALs, error) {
 				return cmp.Compare(s.logNameIndex, li)
 			})
 			if found {
-				return nil, errors.Errorf("wal: duplicate logIndex=%s for WAL %s in %s and %s",
-					li, dfn, d.Dirname, wals[i].segments[j].dir.Dirname)
+				dups = append(dups, duplicateSegment{
+					numWAL:       dfn,
+					logNameIndex: li,
+					kept:         wals[i].segments[j],
+					dup:          segment{logNameIndex: li, dir: d},
+				})
+				continue
 			}
 			wals[i].segments = slices.Insert(wals[i].segments, j, segment{logNameIndex: li, dir: d})
 		}
 	}
-	return wals, nil
+	return wals, dups, nil
 }
 
 func newVirtualWALReader(logNum NumWAL, segments []segment) *virtualWALReader {
+	return newVirtualWALReaderOpts(logNum, segments,
+		false /* repairTail */, false /* useRecordCodecs */, false /* returnLogData */)
+}
+
+// newVirtualWALReaderOpts is like newVirtualWALReader, but additionally
+// accepts repairTail, useRecordCodecs, and returnLogData; see the
+// identically-named fields on virtualWALReader for what each controls. It's
+// used by OpenForRead, the exported entry point real callers (and tests) use
+// to construct a reader with non-default options, and by Checkpoint, which
+// needs returnLogData set so its keep predicate gets a vote on every record;
+// LiveReader goes through newVirtualWALReader directly, since it wants none
+// of the non-default behaviors.
+func newVirtualWALReaderOpts(
+	logNum NumWAL, segments []segment, repairTail, useRecordCodecs, returnLogData bool,
+) *virtualWALReader {
 	return &virtualWALReader{
-		logNum:    logNum,
-		segments:  segments,
-		currIndex: -1,
+		logNum:          logNum,
+		segments:        segments,
+		currIndex:       -1,
+		repairTail:      repairTail,
+		useRecordCodecs: useRecordCodecs,
+		returnLogData:   returnLogData,
 	}
 }
 
@@ -143,8 +207,46 @@ type virtualWALReader struct {
 	// file, and then returned to the user. A pointer to this buffer is returned
 	// directly to the caller of NextRecord.
 	recordBuf bytes.Buffer
+	// codecScratch is reused across calls to NextRecord as scratch space for
+	// decompressing a record's payload, avoiding an allocation per record.
+	codecScratch []byte
+	// repairTail, if set, causes the reader to truncate the final segment
+	// back to the last valid record boundary once it's tolerated a torn
+	// tail there, rather than leaving the torn bytes in place to be
+	// re-tolerated on the next open. See ReaderOptions.RepairTail.
+	repairTail bool
+	// useRecordCodecs, if set, causes the reader to interpret the leading
+	// byte of every record's payload as a codec byte (see codec.go) and
+	// decode accordingly. It must only be set for logical WALs known to
+	// have been written by a RecordWriter, since every other WAL — in
+	// particular every WAL written before this feature existed — carries
+	// raw batchrepr bytes with no such leading byte. See
+	// ReaderOptions.UseRecordCodecs.
+	useRecordCodecs bool
+	// returnLogData, if set, causes the reader to return LogData-only
+	// batches (those with a header Count of zero) to the caller instead of
+	// silently skipping them. Normal WAL recovery has no use for these
+	// batches and skipping them avoids the dedup logic below mistaking a
+	// later batch for a repeat of one already returned, so this stays
+	// false for every reader except Checkpoint's, which needs its keep
+	// predicate to get a vote on every record it might drop.
+	returnLogData bool
+	// curSegmentSize is the physical size, in bytes, that the current
+	// segment (currFile/currReader) was opened against in nextFile. It's
+	// used by LiveReader to detect that a segment has grown since we last
+	// opened it: currReader's view of the file, including any padding
+	// newPaddedSegmentReader synthesized, is fixed at open time and can't
+	// observe bytes fsynced afterwards.
+	curSegmentSize int64
 }
 
+// blockSize is the width, in bytes, of the blocks that the record package
+// fragments records into. It mirrors internal/record's own blockSize. A
+// segment's physical size that isn't a multiple of blockSize indicates the
+// final block was only partially written, for example because of a crash
+// mid-write or filesystem preallocation.
+const blockSize = 32 * 1024
+
 // *virtualWALReader implements wal.Reader.
 var _ Reader = (*virtualWALReader)(nil)
 
@@ -199,10 +301,53 @@ func (r *virtualWALReader) NextRecord() (io.Reader, Offset, error) {
 				return nil, r.off, err
 			}
 			continue
+		} else if errors.Is(err, record.ErrZeroedChunk) && r.off.Physical >= r.curSegmentSize {
+			// The zeroed chunk header lies in the padding that
+			// newPaddedSegmentReader synthesized past the segment's real
+			// content, so the segment ended cleanly: advance exactly as for
+			// io.EOF.
+			if err := r.nextFile(); err != nil {
+				return nil, r.off, err
+			}
+			continue
+		} else if record.IsInvalidRecord(err) && r.repairTail {
+			// We've tolerated the torn tail; truncate it away so that it
+			// isn't re-scanned (and re-tolerated) the next time this
+			// segment is opened.
+			if terr := r.truncateTail(); terr != nil {
+				return nil, r.off, terr
+			}
+			return nil, r.off, io.EOF
 		} else if err != nil {
 			return nil, r.off, err
 		}
 
+		// If this logical WAL was written by a RecordWriter, peel off the
+		// leading codec byte of every record and decompress the payload (a
+		// no-op for codecRaw) before interpreting it as a batch. Replace
+		// recordBuf's contents in place so the *bytes.Buffer we hand back to
+		// the caller continues to expose the decoded representation. Every
+		// other WAL — including every WAL written before this feature
+		// existed — carries raw batchrepr bytes with no such leading byte,
+		// so useRecordCodecs must stay false for those; decoding
+		// unconditionally would misinterpret the batch's own seqnum as a
+		// codec byte.
+		if r.useRecordCodecs {
+			decoded, err := decompressRecord(r.recordBuf.Bytes(), &r.codecScratch)
+			if err != nil {
+				if errors.Is(err, ErrUnknownCodec) {
+					// Wrap rather than format, so callers can still detect
+					// ErrUnknownCodec with errors.Is.
+					return nil, r.off, errors.Wrapf(err, "pebble: log file logNum=%d, logNameIndex=%s",
+						r.logNum, errors.Safe(r.segments[r.currIndex].logNameIndex))
+				}
+				return nil, r.off, base.CorruptionErrorf("pebble: corrupt log file logNum=%d, logNameIndex=%s: %s",
+					r.logNum, errors.Safe(r.segments[r.currIndex].logNameIndex), err)
+			}
+			r.recordBuf.Reset()
+			r.recordBuf.Write(decoded)
+		}
+
 		// We may observe repeat records between the physical files that make up
 		// a virtual WAL because inflight writes to a file on a stalled disk may
 		// or may not end up completing. WAL records always contain encoded
@@ -233,8 +378,17 @@ func (r *virtualWALReader) NextRecord() (io.Reader, Offset, error) {
 		// sequence number. We can differentiate LogData-only batches through
 		// their batch headers: they'll encode a count of zero.
 		if h.Count == 0 {
-			r.recordBuf.Reset()
-			continue
+			if !r.returnLogData {
+				r.recordBuf.Reset()
+				continue
+			}
+			// The caller wants to see LogData batches too. Return this one
+			// without consulting or updating lastSeqNum below: a LogData
+			// batch carries the same sequence number as the batch that
+			// follows it, so it isn't a genuine duplicate, and letting it
+			// advance lastSeqNum would cause that following batch to be
+			// mistaken for a repeat and skipped.
+			return &r.recordBuf, r.off, nil
 		}
 
 		// If we've already observed a sequence number >= this batch's sequence
@@ -249,6 +403,28 @@ func (r *virtualWALReader) NextRecord() (io.Reader, Offset, error) {
 	}
 }
 
+// truncateTail truncates the current segment back to r.off.Physical, the
+// offset of the record that failed to decode. r.off.Physical is clamped to
+// the file's actual size first: when the segment was opened through a
+// paddedSegmentReader (see nextFile), record.Reader.Offset() counts the
+// zero padding synthesized past the real end of the file, so it can exceed
+// the physical file size. Truncating to that unclamped offset would grow
+// the file with zeros instead of shrinking it.
+func (r *virtualWALReader) truncateTail() error {
+	info, err := r.currFile.Stat()
+	if err != nil {
+		return errors.Wrapf(err, "stat'ing WAL file segment %q", r.off.PhysicalFile)
+	}
+	truncateAt := r.off.Physical
+	if truncateAt > info.Size() {
+		truncateAt = info.Size()
+	}
+	if err := r.currFile.Truncate(truncateAt); err != nil {
+		return errors.Wrapf(err, "truncating torn tail of WAL file segment %q", r.off.PhysicalFile)
+	}
+	return nil
+}
+
 // Close closes the reader, releasing open resources.
 func (r *virtualWALReader) Close() error {
 	if r.currFile != nil {
@@ -279,9 +455,87 @@ func (r *virtualWALReader) nextFile() error {
 	r.off.PhysicalFile = path
 	r.off.Physical = 0
 	var err error
-	if r.currFile, err = fs.Open(path); err != nil {
+	if segment.logNameIndex == 0 {
+		// scanLogs lists a checkpoint file written by Checkpoint as
+		// logNameIndex 0 of the WAL it stands in for; open it when no
+		// ordinary segment file by that name exists.
+		ckptPath := fs.PathJoin(segment.dir.Dirname, makeCheckpointFilename(r.logNum))
+		if _, serr := fs.Stat(path); serr != nil {
+			if _, cerr := fs.Stat(ckptPath); cerr == nil {
+				path = ckptPath
+				r.off.PhysicalFile = path
+			}
+		}
+	}
+	if r.repairTail {
+		// truncateTail will need to Truncate this handle if we tolerate a
+		// torn tail in this segment; fs.Open's handle is read-only, and
+		// ftruncate-ing a read-only fd fails on a real, disk-backed FS.
+		r.currFile, err = fs.OpenReadWrite(path)
+	} else {
+		r.currFile, err = fs.Open(path)
+	}
+	if err != nil {
 		return errors.Wrapf(err, "opening WAL file segment %q", path)
 	}
-	r.currReader = record.NewReader(r.currFile, base.DiskFileNum(r.logNum))
+	// If the segment's size isn't a multiple of blockSize, its final block
+	// was only partially written — for example a torn write at a page
+	// boundary left by a crash, or trailing zeros from filesystem
+	// preallocation. Wrap the file so record.Reader sees a zeroed chunk
+	// header there instead of a short read; NextRecord treats a zeroed
+	// header at or past curSegmentSize as the segment's clean end.
+	var rd io.Reader = r.currFile
+	if info, statErr := r.currFile.Stat(); statErr == nil {
+		rd = newPaddedSegmentReader(r.currFile, info.Size())
+		r.curSegmentSize = info.Size()
+	}
+	r.currReader = record.NewReader(rd, base.DiskFileNum(r.logNum))
 	return nil
 }
+
+// newPaddedSegmentReader wraps f, a segment of the given physical size, so
+// that reads past the end of its final, partially-written block return
+// zeros up to the block boundary rather than ending the moment the real
+// data runs out. This gives record.Reader the same "zero header → EOF"
+// shape it would see had the block been fully written and left empty.
+func newPaddedSegmentReader(f vfs.File, size int64) io.Reader {
+	if rem := size % blockSize; rem != 0 {
+		return &paddedSegmentReader{File: f, remaining: size, padding: blockSize - rem}
+	}
+	return f
+}
+
+// paddedSegmentReader is the io.Reader returned by newPaddedSegmentReader
+// when padding is actually necessary.
+type paddedSegmentReader struct {
+	vfs.File
+	remaining int64 // bytes of real file content left to read
+	padding   int64 // zero bytes to synthesize once remaining reaches zero
+}
+
+// Read implements io.Reader.
+func (p *paddedSegmentReader) Read(buf []byte) (int, error) {
+	if p.remaining > 0 {
+		if int64(len(buf)) > p.remaining {
+			buf = buf[:p.remaining]
+		}
+		n, err := p.File.Read(buf)
+		p.remaining -= int64(n)
+		if errors.Is(err, io.EOF) && p.remaining <= 0 {
+			// The real content ended exactly where Stat said it would;
+			// don't propagate EOF yet so the caller observes the padding.
+			err = nil
+		}
+		return n, err
+	}
+	if p.padding > 0 {
+		n := int64(len(buf))
+		if n > p.padding {
+			n = p.padding
+		}
+		clear(buf[:n])
+		p.padding -= n
+		return int(n), nil
+	}
+	return 0, io.EOF
+}
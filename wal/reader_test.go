@@ -0,0 +1,89 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package wal
+
+import (
+	"io"
+	"testing"
+
+	"github.com/cockroachdb/pebble/record"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+func fileSize(t *testing.T, fs vfs.FS, path string) int64 {
+	t.Helper()
+	f, err := fs.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	info, err := f.Stat()
+	require.NoError(t, err)
+	return info.Size()
+}
+
+// TestRepairTailTruncatesTornWrite verifies that, with ReaderOptions.
+// RepairTail set, a segment left with a torn trailing write (as if a crash
+// interrupted it mid-record) is truncated back to the last valid record
+// boundary once that tail has been tolerated, and that a subsequent open of
+// the now-truncated segment no longer sees any error at all.
+func TestRepairTailTruncatesTornWrite(t *testing.T) {
+	fs := vfs.NewMem()
+	dir := Dir{FS: fs, Dirname: "wal"}
+	require.NoError(t, fs.MkdirAll(dir.Dirname, 0755))
+	const logNum = NumWAL(3)
+	path := fs.PathJoin(dir.Dirname, makeLogFilename(logNum, 0))
+
+	f, err := fs.Create(path)
+	require.NoError(t, err)
+	w := record.NewWriter(f)
+	rec1 := makeBatch(1, "first")
+	recWriter, err := w.Next()
+	require.NoError(t, err)
+	_, err = recWriter.Write(rec1)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	goodSize := fileSize(t, fs, path)
+
+	// Simulate a crash partway through writing a second record: append a
+	// few bytes that look like the start of a chunk header but are never
+	// completed.
+	f, err = fs.OpenReadWrite(path)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte{1, 2, 3, 4, 5, 6, 7}, goodSize)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Greater(t, fileSize(t, fs, path), goodSize)
+
+	r, err := OpenForRead([]Dir{dir}, logNum, ReaderOptions{RepairTail: true})
+	require.NoError(t, err)
+
+	rec, _, err := r.NextRecord()
+	require.NoError(t, err)
+	got, err := io.ReadAll(rec)
+	require.NoError(t, err)
+	require.Equal(t, rec1, got)
+
+	_, _, err = r.NextRecord()
+	require.ErrorIs(t, err, io.EOF)
+	require.NoError(t, r.Close())
+
+	require.Equal(t, goodSize, fileSize(t, fs, path))
+
+	// The torn tail is gone, so a subsequent open (even without RepairTail)
+	// sees a clean recovery: the one good record, then a clean EOF with no
+	// error to tolerate.
+	r2, err := OpenForRead([]Dir{dir}, logNum, ReaderOptions{})
+	require.NoError(t, err)
+	defer r2.Close()
+	rec, _, err = r2.NextRecord()
+	require.NoError(t, err)
+	got, err = io.ReadAll(rec)
+	require.NoError(t, err)
+	require.Equal(t, rec1, got)
+	_, _, err = r2.NextRecord()
+	require.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,240 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package wal
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble/batchrepr"
+	"github.com/cockroachdb/pebble/internal/base"
+	"github.com/cockroachdb/pebble/record"
+)
+
+// corruptDirname is the subdirectory, relative to a segment's own
+// directory, into which Repair quarantines segments it can't reconcile.
+const corruptDirname = "corrupt"
+
+// A RepairReport summarizes the inconsistencies Repair found, and
+// quarantined, across the on-disk segments of all logical WALs found in the
+// provided directories.
+type RepairReport struct {
+	// WALs is the logical WAL listing reconstructed purely from what's on
+	// disk, the same listing a subsequent Open would see after quarantining.
+	WALs []RepairedWAL
+	// Duplicates describes every pair of segment files found claiming the
+	// same (NumWAL, logNameIndex) — a collision listLogs would otherwise
+	// refuse to recover from at all. For each, Kept is the segment Repair
+	// retained and folded into WALs (the one scanLogs encountered first);
+	// Quarantined is the one it moved aside.
+	Duplicates []DuplicateSegment
+}
+
+// A DuplicateSegment describes a collision Repair found between two segment
+// files claiming the same (NumWAL, logNameIndex).
+type DuplicateSegment struct {
+	NumWAL            NumWAL
+	LogNameIndex      logNameIndex
+	Kept, Quarantined segment
+}
+
+// A RepairedWAL describes the reconciled state of a single logical WAL.
+type RepairedWAL struct {
+	NumWAL NumWAL
+	// Segments describes each retained segment, in order.
+	Segments []RepairedSegment
+	// Quarantined lists segments that were moved aside into corruptDirname
+	// because their sequence number range was wholly superseded by a
+	// retained segment, and so were excluded from Segments.
+	Quarantined []segment
+}
+
+// A RepairedSegment describes what Repair observed when scanning a single
+// physical segment file end-to-end.
+type RepairedSegment struct {
+	segment
+	// MinSeqNum and MaxSeqNum are the smallest and largest batch sequence
+	// numbers observed among the segment's valid, non-LogData-only records.
+	// They're zero if the segment contained no such records.
+	MinSeqNum, MaxSeqNum uint64
+	// Torn is true if the segment's final record failed to decode. This is
+	// expected at the tail of a WAL that was being actively written to at
+	// the time of a crash, but can also happen on a segment that isn't the
+	// last one in its logical WAL if a failover abandoned it mid-write.
+	Torn bool
+	// Abandoned is true if this segment's MinSeqNum leaves a gap after the
+	// previous retained segment's MaxSeqNum, suggesting the intervening
+	// sequence numbers' batches were never durably written anywhere we can
+	// see, for example because an earlier segment in the chain was lost.
+	Abandoned bool
+	// Overlap is true if this segment's MinSeqNum falls at or below the
+	// previous retained segment's MaxSeqNum, but its MaxSeqNum extends past
+	// it — a partial overlap, as opposed to the wholly superseded case
+	// (where this segment never makes it into Segments at all; see
+	// RepairedWAL.Quarantined). Unlike a full supersession, a partial
+	// overlap isn't safe to quarantine: this segment also covers sequence
+	// numbers the previous one doesn't. It's retained, but flagged so a
+	// caller can investigate why two segments ever disagreed about a
+	// shared range.
+	Overlap bool
+}
+
+// Repair reconstructs the logical WAL listing purely from the segment files
+// present in dirs, without relying on any other persisted state, and
+// reports (and quarantines) inconsistencies it finds: segments whose
+// sequence number ranges are wholly superseded by another segment (the kind
+// of failover duplicate virtualWALReader dedupes at read time), segments
+// whose sequence number range only partially overlaps their predecessor's
+// (flagged via RepairedSegment.Overlap but, unlike full supersession, still
+// retained), segments that appear abandoned because of a non-monotonic
+// sequence number gap from their predecessor, and pairs of segment files
+// that collided on the same (NumWAL, logNameIndex) (reported via
+// RepairReport.Duplicates and quarantined, same as a superseded segment).
+//
+// Repair is intended for recovering from a crash that left a WAL manager's
+// own bookkeeping (e.g. a manifest or other external index) inconsistent
+// with the filesystem; it doesn't depend on that bookkeeping at all, only
+// on scanLogs's directory scan and parseLogFilename.
+//
+// useRecordCodecs must match how the WALs being scanned were written: set it
+// only if every one of them was written through a RecordWriter, exactly as
+// for OpenForRead's identically-named option. Getting this wrong misreads
+// the leading bytes of every batch, producing bogus sequence numbers and
+// incorrect quarantine decisions.
+func Repair(useRecordCodecs bool, dirs ...Dir) (RepairReport, error) {
+	wals, dups, err := scanLogs(dirs...)
+	if err != nil {
+		return RepairReport{}, err
+	}
+
+	var report RepairReport
+	for _, d := range dups {
+		if err := quarantine(d.numWAL, d.dup); err != nil {
+			return RepairReport{}, err
+		}
+		report.Duplicates = append(report.Duplicates, DuplicateSegment{
+			NumWAL:       d.numWAL,
+			LogNameIndex: d.logNameIndex,
+			Kept:         d.kept,
+			Quarantined:  d.dup,
+		})
+	}
+
+	for _, lw := range wals {
+		rw := RepairedWAL{NumWAL: lw.NumWAL}
+		var prevMax uint64
+		for i, seg := range lw.segments {
+			rs, err := scanSegment(lw.NumWAL, seg, useRecordCodecs)
+			if err != nil {
+				return RepairReport{}, err
+			}
+			if i > 0 && rs.MaxSeqNum != 0 && rs.MaxSeqNum <= prevMax {
+				// Wholly superseded by the previous segment's range; this is
+				// the duplicate tail/head overlap virtualWALReader would
+				// skip via lastSeqNum. Quarantine it rather than retain it.
+				if err := quarantine(lw.NumWAL, seg); err != nil {
+					return RepairReport{}, err
+				}
+				rw.Quarantined = append(rw.Quarantined, seg)
+				continue
+			}
+			if i > 0 && rs.MinSeqNum != 0 && prevMax != 0 && rs.MinSeqNum <= prevMax {
+				// A partial overlap: this segment's range extends past
+				// prevMax (otherwise it would have been quarantined above),
+				// but still starts at or before it. Retained — it covers
+				// sequence numbers the previous segment doesn't — but
+				// flagged, since two segments should never disagree about a
+				// shared range.
+				rs.Overlap = true
+			}
+			if rs.MinSeqNum != 0 && prevMax != 0 && rs.MinSeqNum > prevMax+1 {
+				rs.Abandoned = true
+			}
+			if rs.MaxSeqNum != 0 {
+				prevMax = rs.MaxSeqNum
+			}
+			rw.Segments = append(rw.Segments, rs)
+		}
+		report.WALs = append(report.WALs, rw)
+	}
+	return report, nil
+}
+
+// scanSegment reads a single physical segment end-to-end, tolerating a torn
+// tail regardless of whether the segment is the last one belonging to its
+// logical WAL: a failover can leave an earlier segment's tail torn too, the
+// same way virtualWALReader.NextRecord tolerates record.IsInvalidRecord on
+// any segment that isn't the final one in the chain.
+func scanSegment(logNum NumWAL, seg segment, useRecordCodecs bool) (RepairedSegment, error) {
+	fs := seg.dir.FS
+	path := fs.PathJoin(seg.dir.Dirname, makeLogFilename(logNum, seg.logNameIndex))
+	f, err := fs.Open(path)
+	if err != nil {
+		return RepairedSegment{}, errors.Wrapf(err, "opening WAL segment %q", path)
+	}
+	defer f.Close()
+
+	rs := RepairedSegment{segment: seg}
+	rr := record.NewReader(f, base.DiskFileNum(logNum))
+	var buf bytes.Buffer
+	var codecScratch []byte
+	for {
+		rec, err := rr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if record.IsInvalidRecord(err) {
+			// A torn tail is expected: the segment was still being
+			// written to when the crash occurred, or it's an earlier
+			// segment a failover left abandoned mid-write.
+			rs.Torn = true
+			break
+		}
+		if err != nil {
+			return RepairedSegment{}, errors.Wrapf(err, "reading WAL segment %q", path)
+		}
+		buf.Reset()
+		if _, err := buf.ReadFrom(rec); err != nil {
+			return RepairedSegment{}, errors.Wrapf(err, "reading WAL segment %q", path)
+		}
+		recordBytes := buf.Bytes()
+		if useRecordCodecs {
+			decoded, err := decompressRecord(recordBytes, &codecScratch)
+			if err != nil {
+				return RepairedSegment{}, errors.Wrapf(err, "decoding WAL segment %q", path)
+			}
+			recordBytes = decoded
+		}
+		h, ok := batchrepr.ReadHeader(recordBytes)
+		if !ok || h.Count == 0 {
+			continue
+		}
+		if rs.MinSeqNum == 0 || h.SeqNum < rs.MinSeqNum {
+			rs.MinSeqNum = h.SeqNum
+		}
+		if h.SeqNum > rs.MaxSeqNum {
+			rs.MaxSeqNum = h.SeqNum
+		}
+	}
+	return rs, nil
+}
+
+// quarantine moves seg's underlying file into a corruptDirname subdirectory
+// next to it, so that a subsequent listLogs no longer surfaces it.
+func quarantine(logNum NumWAL, seg segment) error {
+	fs := seg.dir.FS
+	dir := fs.PathJoin(seg.dir.Dirname, corruptDirname)
+	if err := fs.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "creating %q", dir)
+	}
+	name := makeLogFilename(logNum, seg.logNameIndex)
+	oldPath := fs.PathJoin(seg.dir.Dirname, name)
+	newPath := fs.PathJoin(dir, name)
+	if err := fs.Rename(oldPath, newPath); err != nil {
+		return errors.Wrapf(err, "quarantining %q", oldPath)
+	}
+	return nil
+}
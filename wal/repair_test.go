@@ -0,0 +1,196 @@
+// Copyright 2026 The LevelDB-Go and Pebble Authors. All rights reserved. Use
+// of this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package wal
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/pebble/record"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRepairQuarantinesSupersededSegment verifies that Repair quarantines a
+// failover segment whose entire sequence number range is already covered by
+// its predecessor, and that it agrees with the reader path on where the
+// batch header starts when the WAL was written through a RecordWriter.
+func TestRepairQuarantinesSupersededSegment(t *testing.T) {
+	fs := vfs.NewMem()
+	dir := Dir{FS: fs, Dirname: "wal"}
+	require.NoError(t, fs.MkdirAll(dir.Dirname, 0755))
+	const logNum = NumWAL(4)
+
+	writeSegment := func(index logNameIndex, batches ...[]byte) {
+		path := fs.PathJoin(dir.Dirname, makeLogFilename(logNum, index))
+		f, err := fs.Create(path)
+		require.NoError(t, err)
+		w := record.NewWriter(f)
+		rw := NewRecordWriter(w, RecordWriterOptions{})
+		for _, b := range batches {
+			require.NoError(t, rw.WriteRecord(b))
+		}
+		require.NoError(t, w.Close())
+		require.NoError(t, f.Close())
+	}
+
+	// Segment 0 covers sequence numbers 1-2; segment 1 is a failover
+	// duplicate whose range (1-2) is wholly superseded by segment 0's.
+	writeSegment(0, makeBatch(1, "a"), makeBatch(2, "b"))
+	writeSegment(1, makeBatch(1, "a"), makeBatch(2, "b"))
+
+	report, err := Repair(true /* useRecordCodecs */, dir)
+	require.NoError(t, err)
+	require.Len(t, report.WALs, 1)
+	rw := report.WALs[0]
+	require.Equal(t, logNum, rw.NumWAL)
+	require.Len(t, rw.Segments, 1)
+	require.Equal(t, logNameIndex(0), rw.Segments[0].logNameIndex)
+	require.EqualValues(t, 1, rw.Segments[0].MinSeqNum)
+	require.EqualValues(t, 2, rw.Segments[0].MaxSeqNum)
+	require.Len(t, rw.Quarantined, 1)
+	require.Equal(t, logNameIndex(1), rw.Quarantined[0].logNameIndex)
+
+	quarantinedPath := fs.PathJoin(dir.Dirname, corruptDirname, makeLogFilename(logNum, 1))
+	_, err = fs.Stat(quarantinedPath)
+	require.NoError(t, err, "quarantined segment should have been moved aside")
+}
+
+// TestRepairFlagsPartialOverlap verifies that Repair retains, but flags via
+// RepairedSegment.Overlap, a segment whose sequence number range only
+// partially overlaps its predecessor's — as opposed to a wholly superseded
+// segment, which it quarantines instead.
+func TestRepairFlagsPartialOverlap(t *testing.T) {
+	fs := vfs.NewMem()
+	dir := Dir{FS: fs, Dirname: "wal"}
+	require.NoError(t, fs.MkdirAll(dir.Dirname, 0755))
+	const logNum = NumWAL(5)
+
+	writeSegment := func(index logNameIndex, batches ...[]byte) {
+		path := fs.PathJoin(dir.Dirname, makeLogFilename(logNum, index))
+		f, err := fs.Create(path)
+		require.NoError(t, err)
+		w := record.NewWriter(f)
+		rw := NewRecordWriter(w, RecordWriterOptions{})
+		for _, b := range batches {
+			require.NoError(t, rw.WriteRecord(b))
+		}
+		require.NoError(t, w.Close())
+		require.NoError(t, f.Close())
+	}
+
+	// Segment 0 covers sequence numbers 1-5; segment 1 covers 3-8, a
+	// partial overlap rather than a full supersession.
+	writeSegment(0, makeBatch(1, "a"), makeBatch(5, "b"))
+	writeSegment(1, makeBatch(3, "c"), makeBatch(8, "d"))
+
+	report, err := Repair(true /* useRecordCodecs */, dir)
+	require.NoError(t, err)
+	require.Len(t, report.WALs, 1)
+	rw := report.WALs[0]
+	require.Empty(t, rw.Quarantined, "a partially overlapping segment must not be quarantined")
+	require.Len(t, rw.Segments, 2)
+	require.False(t, rw.Segments[0].Overlap)
+	require.True(t, rw.Segments[1].Overlap, "segment 1's range (3-8) overlaps segment 0's (1-5)")
+}
+
+// TestRepairToleratesTornNonLastSegment verifies that Repair tolerates a
+// torn tail on a segment that isn't the last one in its logical WAL — a
+// failover can abandon an earlier segment mid-write, not just the final
+// one — flagging it via RepairedSegment.Torn rather than aborting the whole
+// repair with an error.
+func TestRepairToleratesTornNonLastSegment(t *testing.T) {
+	fs := vfs.NewMem()
+	dir := Dir{FS: fs, Dirname: "wal"}
+	require.NoError(t, fs.MkdirAll(dir.Dirname, 0755))
+	const logNum = NumWAL(7)
+
+	// Segment 0: a valid record, followed by a crash mid-write that leaves
+	// a torn trailing chunk header.
+	path0 := fs.PathJoin(dir.Dirname, makeLogFilename(logNum, 0))
+	f, err := fs.Create(path0)
+	require.NoError(t, err)
+	w := record.NewWriter(f)
+	rw := NewRecordWriter(w, RecordWriterOptions{})
+	require.NoError(t, rw.WriteRecord(makeBatch(1, "a")))
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+	goodSize := fileSize(t, fs, path0)
+
+	f, err = fs.OpenReadWrite(path0)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte{1, 2, 3, 4, 5, 6, 7}, goodSize)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// Segment 1: the failover successor, picking up where segment 0 left
+	// off.
+	path1 := fs.PathJoin(dir.Dirname, makeLogFilename(logNum, 1))
+	f, err = fs.Create(path1)
+	require.NoError(t, err)
+	w = record.NewWriter(f)
+	rw = NewRecordWriter(w, RecordWriterOptions{})
+	require.NoError(t, rw.WriteRecord(makeBatch(2, "b")))
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	report, err := Repair(true /* useRecordCodecs */, dir)
+	require.NoError(t, err, "a torn non-last segment must not abort the whole repair")
+	require.Len(t, report.WALs, 1)
+	rw2 := report.WALs[0]
+	require.Len(t, rw2.Segments, 2)
+	require.True(t, rw2.Segments[0].Torn, "segment 0's torn tail should have been tolerated, not treated as corruption")
+	require.False(t, rw2.Segments[1].Torn)
+}
+
+// TestRepairReportsDuplicateLogIndex verifies that Repair tolerates two
+// segment files claiming the same logNameIndex — which listLogs treats as
+// fatal — by quarantining the duplicate and describing the collision via
+// RepairReport.Duplicates, rather than aborting before it can report
+// anything at all.
+func TestRepairReportsDuplicateLogIndex(t *testing.T) {
+	fs := vfs.NewMem()
+	dir := Dir{FS: fs, Dirname: "wal"}
+	otherDir := Dir{FS: fs, Dirname: "wal2"}
+	require.NoError(t, fs.MkdirAll(dir.Dirname, 0755))
+	require.NoError(t, fs.MkdirAll(otherDir.Dirname, 0755))
+	const logNum = NumWAL(6)
+
+	writeSegment := func(d Dir, index logNameIndex, batches ...[]byte) {
+		path := fs.PathJoin(d.Dirname, makeLogFilename(logNum, index))
+		f, err := fs.Create(path)
+		require.NoError(t, err)
+		w := record.NewWriter(f)
+		rw := NewRecordWriter(w, RecordWriterOptions{})
+		for _, b := range batches {
+			require.NoError(t, rw.WriteRecord(b))
+		}
+		require.NoError(t, w.Close())
+		require.NoError(t, f.Close())
+	}
+
+	// Both directories claim logNameIndex 0 for the same logical WAL — a
+	// collision listLogs would refuse to recover from.
+	writeSegment(dir, 0, makeBatch(1, "a"))
+	writeSegment(otherDir, 0, makeBatch(1, "a"))
+
+	_, err := listLogs(dir, otherDir)
+	require.Error(t, err, "listLogs should still refuse a duplicate logNameIndex")
+
+	report, err := Repair(true /* useRecordCodecs */, dir, otherDir)
+	require.NoError(t, err)
+	require.Len(t, report.Duplicates, 1)
+	dup := report.Duplicates[0]
+	require.Equal(t, logNum, dup.NumWAL)
+	require.Equal(t, logNameIndex(0), dup.LogNameIndex)
+	require.Equal(t, dir.Dirname, dup.Kept.dir.Dirname)
+	require.Equal(t, otherDir.Dirname, dup.Quarantined.dir.Dirname)
+
+	require.Len(t, report.WALs, 1)
+	require.Len(t, report.WALs[0].Segments, 1)
+
+	quarantinedPath := fs.PathJoin(otherDir.Dirname, corruptDirname, makeLogFilename(logNum, 0))
+	_, err = fs.Stat(quarantinedPath)
+	require.NoError(t, err, "the duplicate segment should have been moved aside")
+}